@@ -0,0 +1,47 @@
+/*
+ * Copyright (C) 2015 Fabrício Godoy <skarllot@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, write to the Free Software
+ * Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+ */
+
+package appcontext
+
+import "time"
+
+// A Backend stores the values associated to tokens and their expiry. It is
+// the storage contract that TokenStore relies upon, so that TimedStore (an
+// in-memory, single-process store) can be swapped for a durable or shared
+// implementation without changing TokenStore's API.
+type Backend interface {
+	// AddValue stores value under a newly created token and reports whether
+	// the token already existed.
+	AddValue(token string, value interface{}) (bool, error)
+
+	// GetValue gets the value stored by specified token.
+	GetValue(token string) (interface{}, error)
+
+	// SetValue stores a value to specified token, keeping its current
+	// expiry.
+	SetValue(token string, value interface{}) error
+
+	// RemoveValue removes specified token from the backend.
+	RemoveValue(token string) error
+
+	// SetValueDuration updates the lifetime of specified token.
+	SetValueDuration(token string, duration time.Duration) error
+
+	// Count gets the number of tokens currently stored by the backend.
+	Count() int
+}
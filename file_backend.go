@@ -0,0 +1,198 @@
+/*
+ * Copyright (C) 2015 Fabrício Godoy <skarllot@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, write to the Free Software
+ * Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+ */
+
+package appcontext
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileBackendEntry is the on-disk representation of a single token.
+type fileBackendEntry struct {
+	Value    interface{} `json:"value"`
+	ExpireAt time.Time   `json:"expireAt"`
+}
+
+// A FileBackend is a Backend that persists tokens to a JSON file on disk, so
+// that they survive a process restart. It is intended for single-instance
+// deployments that still want durability; for multi-instance deployments
+// prefer SQLBackend or a KVBackend pointed at a shared store.
+type FileBackend struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileBackend creates a FileBackend that reads and writes tokens to path.
+// The file is created on first write if it does not already exist.
+func NewFileBackend(path string) *FileBackend {
+	return &FileBackend{path: path}
+}
+
+// AddValue stores value under a newly created token and reports whether the
+// token already existed.
+func (b *FileBackend) AddValue(token string, value interface{}) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries, err := b.load()
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := entries[token]; ok {
+		return true, nil
+	}
+
+	entries[token] = fileBackendEntry{Value: value}
+	return false, b.save(entries)
+}
+
+// GetValue gets the value stored by specified token.
+func (b *FileBackend) GetValue(token string) (interface{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries, err := b.load()
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := entries[token]
+	if !ok || b.isExpired(entry) {
+		return nil, errors.New("the requested token does not exist")
+	}
+	return entry.Value, nil
+}
+
+// SetValue stores a value to specified token, keeping its current expiry.
+func (b *FileBackend) SetValue(token string, value interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries, err := b.load()
+	if err != nil {
+		return err
+	}
+
+	entry, ok := entries[token]
+	if !ok || b.isExpired(entry) {
+		return errors.New("the requested token does not exist")
+	}
+
+	entry.Value = value
+	entries[token] = entry
+	return b.save(entries)
+}
+
+// RemoveValue removes specified token from the backend.
+func (b *FileBackend) RemoveValue(token string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries, err := b.load()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := entries[token]; !ok {
+		return errors.New("the requested token does not exist")
+	}
+
+	delete(entries, token)
+	return b.save(entries)
+}
+
+// SetValueDuration updates the lifetime of specified token.
+func (b *FileBackend) SetValueDuration(token string, duration time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries, err := b.load()
+	if err != nil {
+		return err
+	}
+
+	entry, ok := entries[token]
+	if !ok || b.isExpired(entry) {
+		return errors.New("the requested token does not exist")
+	}
+
+	entry.ExpireAt = time.Now().Add(duration)
+	entries[token] = entry
+	return b.save(entries)
+}
+
+// Count gets the number of non-expired tokens currently stored in the file.
+func (b *FileBackend) Count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries, err := b.load()
+	if err != nil {
+		return 0
+	}
+
+	count := 0
+	for _, entry := range entries {
+		if !b.isExpired(entry) {
+			count++
+		}
+	}
+	return count
+}
+
+// isExpired reports whether entry has passed its expiry. A zero ExpireAt
+// means the entry never expires.
+func (b *FileBackend) isExpired(entry fileBackendEntry) bool {
+	return !entry.ExpireAt.IsZero() && time.Now().After(entry.ExpireAt)
+}
+
+// load reads and decodes the backing file, returning an empty map if the
+// file does not exist yet.
+func (b *FileBackend) load() (map[string]fileBackendEntry, error) {
+	entries := make(map[string]fileBackendEntry)
+
+	data, err := os.ReadFile(b.path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return entries, nil
+	}
+
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// save encodes and writes entries to the backing file.
+func (b *FileBackend) save(entries map[string]fileBackendEntry) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(b.path, data, 0600)
+}
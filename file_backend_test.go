@@ -0,0 +1,54 @@
+/*
+ * Copyright (C) 2015 Fabrício Godoy <skarllot@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, write to the Free Software
+ * Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+ */
+
+package appcontext
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileBackend_SetValueDurationExpiresToken(t *testing.T) {
+	backend := NewFileBackend(filepath.Join(t.TempDir(), "tokens.json"))
+
+	if _, err := backend.AddValue("tok", nil); err != nil {
+		t.Fatalf("AddValue: %v", err)
+	}
+	if err := backend.SetValueDuration("tok", time.Millisecond); err != nil {
+		t.Fatalf("SetValueDuration: %v", err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if _, err := backend.GetValue("tok"); err == nil {
+		t.Fatal("expected expired token to be rejected")
+	}
+}
+
+func TestTokenStore_NewTokenExpiresUnauthenticatedOnFileBackend(t *testing.T) {
+	backend := NewFileBackend(filepath.Join(t.TempDir(), "tokens.json"))
+	store := NewTokenStoreWithBackend(backend, time.Millisecond, time.Hour, "salt")
+
+	token := store.NewToken()
+	time.Sleep(5 * time.Millisecond)
+
+	if _, err := store.GetValue(token); err == nil {
+		t.Fatal("unauthenticated token should have expired per noAuth duration, but is still valid")
+	}
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright (C) 2015 Fabrício Godoy <skarllot@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, write to the Free Software
+ * Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+ */
+
+package appcontext
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// hkdfSHA256 derives length bytes of key material from secret and salt,
+// following the extract-then-expand construction of RFC 5869 (HKDF) using
+// SHA-256 as the hash function. info binds the derived key to its purpose,
+// as recommended by the RFC.
+//
+// This is a small, self-contained implementation rather than a dependency
+// on golang.org/x/crypto/hkdf, since the two extra HMAC calls it wraps are
+// not worth an external module for a single call site.
+func hkdfSHA256(secret, salt, info []byte, length int) []byte {
+	prk := hkdfExtract(secret, salt)
+	return hkdfExpand(prk, info, length)
+}
+
+// hkdfExtract implements the RFC 5869 "extract" step: PRK = HMAC-Hash(salt, IKM).
+func hkdfExtract(ikm, salt []byte) []byte {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(ikm)
+	return mac.Sum(nil)
+}
+
+// hkdfExpand implements the RFC 5869 "expand" step, producing length bytes
+// of output key material from prk and info.
+func hkdfExpand(prk, info []byte, length int) []byte {
+	hashLen := sha256.Size
+	n := (length + hashLen - 1) / hashLen
+
+	okm := make([]byte, 0, n*hashLen)
+	var prev []byte
+	for i := 1; i <= n; i++ {
+		mac := hmac.New(sha256.New, prk)
+		mac.Write(prev)
+		mac.Write(info)
+		mac.Write([]byte{byte(i)})
+		prev = mac.Sum(nil)
+		okm = append(okm, prev...)
+	}
+
+	return okm[:length]
+}
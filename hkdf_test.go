@@ -0,0 +1,56 @@
+/*
+ * Copyright (C) 2015 Fabrício Godoy <skarllot@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, write to the Free Software
+ * Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+ */
+
+package appcontext
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHkdfSHA256IsDeterministic(t *testing.T) {
+	secret := []byte("root-salt")
+	salt := []byte("nonce-a")
+	info := []byte("contextstore.NewToken")
+
+	a := hkdfSHA256(secret, salt, info, 32)
+	b := hkdfSHA256(secret, salt, info, 32)
+
+	if !bytes.Equal(a, b) {
+		t.Fatal("hkdfSHA256 should be deterministic for identical inputs")
+	}
+}
+
+func TestHkdfSHA256DiffersBySalt(t *testing.T) {
+	secret := []byte("root-salt")
+	info := []byte("contextstore.NewToken")
+
+	a := hkdfSHA256(secret, []byte("nonce-a"), info, 32)
+	b := hkdfSHA256(secret, []byte("nonce-b"), info, 32)
+
+	if bytes.Equal(a, b) {
+		t.Fatal("hkdfSHA256 should produce different output for different salts")
+	}
+}
+
+func TestHkdfSHA256ExpandsBeyondHashSize(t *testing.T) {
+	out := hkdfSHA256([]byte("secret"), []byte("salt"), []byte("info"), 100)
+	if len(out) != 100 {
+		t.Fatalf("expected 100 bytes of output key material, got %d", len(out))
+	}
+}
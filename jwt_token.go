@@ -0,0 +1,188 @@
+/*
+ * Copyright (C) 2015 Fabrício Godoy <skarllot@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, write to the Free Software
+ * Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+ */
+
+package appcontext
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// jwtHeader is the fixed compact JWT header used by NewTokenWithClaims: this
+// store only ever signs with HS256.
+var jwtHeader = map[string]interface{}{"alg": "HS256", "typ": "JWT"}
+
+// NewTokenWithClaims issues a compact HS256 JWT carrying claims plus the
+// standard "iat", "exp" and "jti" claims ("iat"/"exp" are only filled in
+// when claims does not already set them, so callers can override the
+// lifetime; an "exp" that is not a number is replaced). The token's jti is also registered with the backing Backend
+// with no value, so RemoveToken(jti) revokes it even though ParseJWT never
+// needs to look the token up to validate its signature and expiry. The
+// jti entry's own backend expiry is pegged to the JWT's "exp" claim, so it
+// does not outlive the token it revokes.
+func (s *TokenStore) NewTokenWithClaims(claims map[string]interface{}) (string, error) {
+	now := time.Now()
+	jti := base64.URLEncoding.EncodeToString(getRandomBytes(16))
+
+	full := make(map[string]interface{}, len(claims)+3)
+	for k, v := range claims {
+		full[k] = v
+	}
+	if _, ok := full["iat"]; !ok {
+		full["iat"] = now.Unix()
+	}
+	exp, ok := unixSecondsClaim(full["exp"])
+	if !ok {
+		exp = now.Add(s.authDuration).Unix()
+		full["exp"] = exp
+	}
+	full["jti"] = jti
+
+	token, err := s.signJWT(full)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := s.tstore.AddValue(jti, nil); err != nil {
+		return "", errors.New("could not register token for revocation")
+	}
+	if err := s.tstore.SetValueDuration(jti, time.Until(time.Unix(exp, 0))); err != nil {
+		return "", errors.New("could not peg revocation entry to the token's expiry")
+	}
+
+	return token, nil
+}
+
+// ParseJWT verifies the HMAC signature of a token issued by
+// NewTokenWithClaims, checks its "exp"/"nbf" claims and, if present,
+// confirms its "jti" has not been revoked via RemoveToken. It returns the
+// decoded claims on success.
+func (s *TokenStore) ParseJWT(token string) (map[string]interface{}, error) {
+	claims, err := s.verifyJWT(token)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	if exp, ok := numericClaim(claims, "exp"); ok && now > exp {
+		return nil, s.getInvalidTokenError(token)
+	}
+	if nbf, ok := numericClaim(claims, "nbf"); ok && now < nbf {
+		return nil, s.getInvalidTokenError(token)
+	}
+
+	if jti, ok := claims["jti"].(string); ok {
+		if _, err := s.tstore.GetValue(jti); err != nil {
+			return nil, s.getInvalidTokenError(token)
+		}
+	}
+
+	return claims, nil
+}
+
+// signJWT encodes claims as a compact JWT and signs it with the store's
+// salt.
+func (s *TokenStore) signJWT(claims map[string]interface{}) (string, error) {
+	headerJSON, err := json.Marshal(jwtHeader)
+	if err != nil {
+		return "", err
+	}
+	payloadJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." +
+		base64.RawURLEncoding.EncodeToString(payloadJSON)
+
+	mac := hmac.New(sha256.New, s.salt)
+	mac.Write([]byte(signingInput))
+	signature := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	return signingInput + "." + signature, nil
+}
+
+// verifyJWT checks the structure and HMAC signature of token and decodes
+// its payload into a claims map.
+func (s *TokenStore) verifyJWT(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, s.getInvalidTokenError(token)
+	}
+
+	signingInput := parts[0] + "." + parts[1]
+	mac := hmac.New(sha256.New, s.salt)
+	mac.Write([]byte(signingInput))
+	expectedSig := mac.Sum(nil)
+
+	gotSig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || subtle.ConstantTimeCompare(gotSig, expectedSig) != 1 {
+		return nil, s.getInvalidTokenError(token)
+	}
+
+	payloadJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, s.getInvalidTokenError(token)
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payloadJSON, &claims); err != nil {
+		return nil, s.getInvalidTokenError(token)
+	}
+
+	return claims, nil
+}
+
+// numericClaim reads a JSON-decoded numeric claim, which json.Unmarshal
+// always produces as float64 when decoding into interface{}.
+func numericClaim(claims map[string]interface{}, name string) (int64, bool) {
+	v, ok := claims[name]
+	if !ok {
+		return 0, false
+	}
+	f, ok := v.(float64)
+	if !ok {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+// unixSecondsClaim reads a unix-seconds claim value supplied directly by a
+// caller of NewTokenWithClaims, which (unlike a claim decoded off the wire
+// by ParseJWT) may be any of Go's native integer or float types rather than
+// only float64.
+func unixSecondsClaim(v interface{}) (int64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return int64(rv.Float()), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright (C) 2015 Fabrício Godoy <skarllot@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, write to the Free Software
+ * Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+ */
+
+package appcontext
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// A KVClient is the minimal contract a third-party key-value store (Redis,
+// Memcached, etcd, ...) must expose to back a KVBackend. Adapting an
+// existing client to KVClient is typically a small wrapper type.
+type KVClient interface {
+	// Get returns the raw bytes stored under key, or an error if key does
+	// not exist or has expired.
+	Get(key string) ([]byte, error)
+
+	// Set stores raw bytes under key with the given time-to-live. A ttl of
+	// zero means the key never expires.
+	Set(key string, data []byte, ttl time.Duration) error
+
+	// Delete removes key, returning an error if it does not exist.
+	Delete(key string) error
+
+	// Exists reports whether key is currently present.
+	Exists(key string) (bool, error)
+}
+
+// A KVBackend is a Backend adapter over a KVClient, letting TokenStore be
+// backed by any external key-value store that implements KVClient.
+type KVBackend struct {
+	client KVClient
+}
+
+// NewKVBackend creates a KVBackend that stores tokens through client.
+func NewKVBackend(client KVClient) *KVBackend {
+	return &KVBackend{client: client}
+}
+
+// kvEnvelope wraps the caller's value together with its expiry, since
+// KVClient does not let a later SetValue read back the TTL a previous
+// Set call configured.
+type kvEnvelope struct {
+	Value    interface{} `json:"value"`
+	ExpireAt time.Time   `json:"expireAt"`
+}
+
+// AddValue stores value under a newly created token and reports whether the
+// token already existed.
+func (b *KVBackend) AddValue(token string, value interface{}) (bool, error) {
+	exists, err := b.client.Exists(token)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return true, nil
+	}
+
+	encoded, err := json.Marshal(kvEnvelope{Value: value})
+	if err != nil {
+		return false, err
+	}
+	return false, b.client.Set(token, encoded, 0)
+}
+
+// GetValue gets the value stored by specified token.
+func (b *KVBackend) GetValue(token string) (interface{}, error) {
+	envelope, err := b.get(token)
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Value, nil
+}
+
+// SetValue stores a value to specified token, keeping its current expiry.
+func (b *KVBackend) SetValue(token string, value interface{}) error {
+	envelope, err := b.get(token)
+	if err != nil {
+		return err
+	}
+	envelope.Value = value
+	return b.put(token, envelope)
+}
+
+// RemoveValue removes specified token from the backend.
+func (b *KVBackend) RemoveValue(token string) error {
+	return b.client.Delete(token)
+}
+
+// SetValueDuration updates the lifetime of specified token.
+func (b *KVBackend) SetValueDuration(token string, duration time.Duration) error {
+	envelope, err := b.get(token)
+	if err != nil {
+		return err
+	}
+	envelope.ExpireAt = time.Now().Add(duration)
+	return b.put(token, envelope)
+}
+
+// Count is not supported by the generic KVClient contract, since most
+// key-value protocols do not expose an efficient way to count keys scoped
+// to this backend. It always returns 0; use the underlying store's own
+// tooling to inspect key counts.
+func (b *KVBackend) Count() int {
+	return 0
+}
+
+// get reads and decodes the envelope stored under token, treating an
+// envelope past its own ExpireAt as if the key did not exist even if the
+// underlying KVClient has not evicted it yet.
+func (b *KVBackend) get(token string) (kvEnvelope, error) {
+	data, err := b.client.Get(token)
+	if err != nil {
+		return kvEnvelope{}, err
+	}
+
+	var envelope kvEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return kvEnvelope{}, err
+	}
+	if !envelope.ExpireAt.IsZero() && time.Now().After(envelope.ExpireAt) {
+		return kvEnvelope{}, errors.New("the requested token does not exist")
+	}
+
+	return envelope, nil
+}
+
+// put encodes envelope and writes it back to the client, re-deriving the
+// TTL to pass to Set from envelope.ExpireAt so the expiry is preserved
+// across value updates.
+func (b *KVBackend) put(token string, envelope kvEnvelope) error {
+	encoded, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	var ttl time.Duration
+	if !envelope.ExpireAt.IsZero() {
+		ttl = time.Until(envelope.ExpireAt)
+		if ttl <= 0 {
+			return errors.New("the requested token does not exist")
+		}
+	}
+
+	return b.client.Set(token, encoded, ttl)
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright (C) 2015 Fabrício Godoy <skarllot@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, write to the Free Software
+ * Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+ */
+
+package appcontext
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeKVClient is a minimal in-memory KVClient used to test KVBackend
+// without a real external store.
+type fakeKVClient struct {
+	mu     sync.Mutex
+	data   map[string][]byte
+	expire map[string]time.Time
+}
+
+func newFakeKVClient() *fakeKVClient {
+	return &fakeKVClient{data: make(map[string][]byte), expire: make(map[string]time.Time)}
+}
+
+func (c *fakeKVClient) expired(key string) bool {
+	exp, ok := c.expire[key]
+	return ok && !exp.IsZero() && time.Now().After(exp)
+}
+
+func (c *fakeKVClient) Get(key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v, ok := c.data[key]
+	if !ok || c.expired(key) {
+		return nil, errors.New("key does not exist")
+	}
+	return v, nil
+}
+
+func (c *fakeKVClient) Set(key string, data []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.data[key] = data
+	if ttl > 0 {
+		c.expire[key] = time.Now().Add(ttl)
+	} else {
+		delete(c.expire, key)
+	}
+	return nil
+}
+
+func (c *fakeKVClient) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.data[key]; !ok {
+		return errors.New("key does not exist")
+	}
+	delete(c.data, key)
+	delete(c.expire, key)
+	return nil
+}
+
+func (c *fakeKVClient) Exists(key string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.data[key]
+	return ok && !c.expired(key), nil
+}
+
+func TestTokenStore_NewTokenExpiresUnauthenticatedOnKVBackend(t *testing.T) {
+	store := NewTokenStoreWithBackend(NewKVBackend(newFakeKVClient()), time.Millisecond, time.Hour, "salt")
+
+	token := store.NewToken()
+	time.Sleep(5 * time.Millisecond)
+
+	if _, err := store.GetValue(token); err == nil {
+		t.Fatal("unauthenticated token should have expired per noAuth duration, but is still valid")
+	}
+}
+
+func TestKVBackend_SetValuePreservesExpiry(t *testing.T) {
+	backend := NewKVBackend(newFakeKVClient())
+
+	if _, err := backend.AddValue("token", "v1"); err != nil {
+		t.Fatalf("AddValue: %v", err)
+	}
+	if err := backend.SetValueDuration("token", 5*time.Millisecond); err != nil {
+		t.Fatalf("SetValueDuration: %v", err)
+	}
+
+	if err := backend.SetValue("token", "v2"); err != nil {
+		t.Fatalf("SetValue: %v", err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	if _, err := backend.GetValue("token"); err == nil {
+		t.Fatal("SetValue should not have reset the token's expiry to never")
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright (C) 2015 Fabrício Godoy <skarllot@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, write to the Free Software
+ * Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+ */
+
+package appcontext
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// memoryBackend is a minimal in-memory Backend used across the test files in
+// this package, so tests exercise TokenStore's own logic (noAuth/auth
+// expiry, pairing, revocation) without depending on TimedStore or any
+// external store.
+type memoryBackend struct {
+	mu      sync.Mutex
+	entries map[string]memoryBackendEntry
+}
+
+type memoryBackendEntry struct {
+	value    interface{}
+	expireAt time.Time
+}
+
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{entries: make(map[string]memoryBackendEntry)}
+}
+
+func (b *memoryBackend) AddValue(token string, value interface{}) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.entries[token]; ok {
+		return true, nil
+	}
+	b.entries[token] = memoryBackendEntry{value: value}
+	return false, nil
+}
+
+func (b *memoryBackend) GetValue(token string) (interface{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, ok := b.entries[token]
+	if !ok || b.expired(e) {
+		return nil, errors.New("the requested token does not exist")
+	}
+	return e.value, nil
+}
+
+func (b *memoryBackend) SetValue(token string, value interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, ok := b.entries[token]
+	if !ok || b.expired(e) {
+		return errors.New("the requested token does not exist")
+	}
+	e.value = value
+	b.entries[token] = e
+	return nil
+}
+
+func (b *memoryBackend) RemoveValue(token string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.entries[token]; !ok {
+		return errors.New("the requested token does not exist")
+	}
+	delete(b.entries, token)
+	return nil
+}
+
+func (b *memoryBackend) SetValueDuration(token string, duration time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, ok := b.entries[token]
+	if !ok || b.expired(e) {
+		return errors.New("the requested token does not exist")
+	}
+	e.expireAt = time.Now().Add(duration)
+	b.entries[token] = e
+	return nil
+}
+
+func (b *memoryBackend) Count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	count := 0
+	for _, e := range b.entries {
+		if !b.expired(e) {
+			count++
+		}
+	}
+	return count
+}
+
+func (b *memoryBackend) expired(e memoryBackendEntry) bool {
+	return !e.expireAt.IsZero() && time.Now().After(e.expireAt)
+}
@@ -0,0 +1,191 @@
+/*
+ * Copyright (C) 2015 Fabrício Godoy <skarllot@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, write to the Free Software
+ * Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+ */
+
+package appcontext
+
+import (
+	"encoding/base64"
+	"time"
+)
+
+// defaultRefreshDuration is the lifetime applied to refresh tokens created
+// by NewTokenPair when SetRefreshDuration has not been called.
+const defaultRefreshDuration = 30 * 24 * time.Hour
+
+// refreshKeyPrefix and pairKeyPrefix namespace the backend entries used to
+// track refresh tokens and access/refresh pairing, keeping them out of the
+// way of the plain token entries created by NewToken.
+const (
+	refreshKeyPrefix = "refresh:"
+	pairKeyPrefix    = "pair:"
+)
+
+// NewTokenPair creates an access token with the usual short lifetime and a
+// refresh token with a much longer one, and links the two so that revoking
+// either one revokes both, and authenticating the access token renews the
+// refresh token's lifetime. The refresh token itself cannot be passed to
+// SetTokenAsAuthenticated. Use Refresh to exchange the refresh token for a
+// new pair once the access token expires.
+func (s *TokenStore) NewTokenPair() (access, refresh string) {
+	access = s.NewToken()
+	refresh = s.newLinkedRefreshToken(access)
+	return access, refresh
+}
+
+// Refresh validates refresh, atomically revokes it along with its paired
+// access token, and issues a brand new pair. Rotating both tokens on every
+// refresh limits the blast radius of a stolen refresh token, since a reused
+// refresh token is no longer valid once rotated.
+//
+// The whole read-verify-revoke-reissue sequence runs under refreshMu so that
+// two concurrent calls with the same refresh token cannot both succeed: the
+// first to acquire the lock removes the refresh token's entry, so the second
+// observes it gone and fails.
+func (s *TokenStore) Refresh(refresh string) (newAccess, newRefresh string, err error) {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	v, err := s.tstore.GetValue(refreshKeyPrefix + refresh)
+	if err != nil {
+		return "", "", s.getInvalidTokenError(refresh)
+	}
+
+	access, ok := v.(string)
+	if !ok {
+		return "", "", s.getInvalidTokenError(refresh)
+	}
+
+	if err := s.tstore.RemoveValue(refreshKeyPrefix + refresh); err != nil {
+		return "", "", s.getInvalidTokenError(refresh)
+	}
+	s.tstore.RemoveValue(pairKeyPrefix + access)
+	s.tstore.RemoveValue(access)
+
+	s.mu.Lock()
+	delete(s.meta, access)
+	s.mu.Unlock()
+
+	newAccess = s.NewToken()
+	newRefresh = s.newLinkedRefreshToken(newAccess)
+	return newAccess, newRefresh, nil
+}
+
+// SetRefreshDuration configures the lifetime applied to refresh tokens
+// created by subsequent calls to NewTokenPair. Existing refresh tokens keep
+// their current expiry.
+func (s *TokenStore) SetRefreshDuration(d time.Duration) {
+	s.refreshDuration = d
+}
+
+// newLinkedRefreshToken creates a refresh token for access, storing both
+// directions of the pairing so RemoveToken and SetTokenAsAuthenticated can
+// cascade to the counterpart token.
+func (s *TokenStore) newLinkedRefreshToken(access string) string {
+	refresh := base64.URLEncoding.EncodeToString(getRandomBytes(32))
+
+	duration := s.refreshDuration
+	if duration == 0 {
+		duration = defaultRefreshDuration
+	}
+
+	s.tstore.AddValue(refreshKeyPrefix+refresh, access)
+	s.tstore.SetValueDuration(refreshKeyPrefix+refresh, duration)
+	s.tstore.AddValue(pairKeyPrefix+access, refresh)
+	s.tstore.SetValueDuration(pairKeyPrefix+access, duration)
+
+	return refresh
+}
+
+// revokePairedToken removes the refresh token paired with access, if any,
+// so that revoking the access token also invalidates its refresh token.
+// Revoking a session via its refresh token instead is handled directly by
+// removeByRefreshToken, since refresh tokens are never stored under their
+// own bare key.
+//
+// It takes refreshMu, matching Refresh and removeByRefreshToken, so that it
+// cannot race a concurrent Refresh of the very refresh token it is about to
+// revoke.
+func (s *TokenStore) revokePairedToken(access string) {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	v, err := s.tstore.GetValue(pairKeyPrefix + access)
+	if err != nil {
+		return
+	}
+	if refresh, ok := v.(string); ok {
+		s.tstore.RemoveValue(refreshKeyPrefix + refresh)
+	}
+	s.tstore.RemoveValue(pairKeyPrefix + access)
+}
+
+// removeByRefreshToken revokes the access/refresh pair identified by a
+// refresh token. It returns an error (without modifying anything) when
+// token is not a known refresh token, so RemoveToken can fall back to
+// treating token as a bare access token.
+func (s *TokenStore) removeByRefreshToken(refresh string) error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	v, err := s.tstore.GetValue(refreshKeyPrefix + refresh)
+	if err != nil {
+		return err
+	}
+
+	access, ok := v.(string)
+	if !ok {
+		return s.getInvalidTokenError(refresh)
+	}
+
+	s.tstore.RemoveValue(refreshKeyPrefix + refresh)
+	s.tstore.RemoveValue(pairKeyPrefix + access)
+	s.tstore.RemoveValue(access)
+
+	s.mu.Lock()
+	delete(s.meta, access)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// renewPairedRefreshToken extends the refresh token paired with access, if
+// any, to the currently configured refresh duration.
+//
+// It takes refreshMu, matching Refresh and removeByRefreshToken, so that
+// renewing the pairing cannot race a concurrent Refresh of the very
+// refresh token being renewed.
+func (s *TokenStore) renewPairedRefreshToken(access string) {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	v, err := s.tstore.GetValue(pairKeyPrefix + access)
+	if err != nil {
+		return
+	}
+	refresh, ok := v.(string)
+	if !ok {
+		return
+	}
+
+	duration := s.refreshDuration
+	if duration == 0 {
+		duration = defaultRefreshDuration
+	}
+	s.tstore.SetValueDuration(refreshKeyPrefix+refresh, duration)
+	s.tstore.SetValueDuration(pairKeyPrefix+access, duration)
+}
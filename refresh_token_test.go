@@ -0,0 +1,119 @@
+/*
+ * Copyright (C) 2015 Fabrício Godoy <skarllot@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, write to the Free Software
+ * Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+ */
+
+package appcontext
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestTokenStore() *TokenStore {
+	return NewTokenStoreWithBackend(newMemoryBackend(), time.Hour, time.Hour, "salt")
+}
+
+func TestTokenStore_ConcurrentRefreshOnlyRotatesOnce(t *testing.T) {
+	store := newTestTokenStore()
+	_, refresh := store.NewTokenPair()
+
+	const callers = 8
+	var wg sync.WaitGroup
+	successes := make(chan struct{}, callers)
+
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := store.Refresh(refresh); err == nil {
+				successes <- struct{}{}
+			}
+		}()
+	}
+	wg.Wait()
+	close(successes)
+
+	count := 0
+	for range successes {
+		count++
+	}
+	if count != 1 {
+		t.Fatalf("expected exactly one concurrent Refresh call to succeed, got %d", count)
+	}
+
+	if _, _, err := store.Refresh(refresh); err == nil {
+		t.Fatal("a rotated refresh token should no longer be redeemable")
+	}
+}
+
+func TestTokenStore_RemoveTokenByRefreshTokenRevokesBoth(t *testing.T) {
+	store := newTestTokenStore()
+	access, refresh := store.NewTokenPair()
+
+	if err := store.RemoveToken(refresh); err != nil {
+		t.Fatalf("RemoveToken(refresh): %v", err)
+	}
+
+	if _, err := store.GetValue(access); err == nil {
+		t.Fatal("access token should have been revoked along with its refresh token")
+	}
+	if _, _, err := store.Refresh(refresh); err == nil {
+		t.Fatal("revoked refresh token should no longer be redeemable")
+	}
+}
+
+func TestTokenStore_RemoveTokenRacingRefreshNeverLeavesRefreshUsable(t *testing.T) {
+	store := newTestTokenStore()
+
+	const attempts = 50
+	for i := 0; i < attempts; i++ {
+		access, refresh := store.NewTokenPair()
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			store.RemoveToken(access)
+		}()
+		go func() {
+			defer wg.Done()
+			store.Refresh(refresh)
+		}()
+		wg.Wait()
+
+		// Whichever of RemoveToken/Refresh won the race, the original
+		// refresh token must not still be redeemable afterwards: either
+		// RemoveToken revoked it outright, or Refresh already rotated it.
+		if _, _, err := store.Refresh(refresh); err == nil {
+			t.Fatalf("attempt %d: refresh token redeemable after racing RemoveToken(access)", i)
+		}
+	}
+}
+
+func TestTokenStore_RemoveTokenByAccessTokenRevokesRefresh(t *testing.T) {
+	store := newTestTokenStore()
+	access, refresh := store.NewTokenPair()
+
+	if err := store.RemoveToken(access); err != nil {
+		t.Fatalf("RemoveToken(access): %v", err)
+	}
+
+	if _, _, err := store.Refresh(refresh); err == nil {
+		t.Fatal("refresh token paired with a revoked access token should no longer be redeemable")
+	}
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright (C) 2015 Fabrício Godoy <skarllot@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, write to the Free Software
+ * Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+ */
+
+package appcontext
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// A SQLBackend is a Backend that persists tokens through database/sql, so
+// that tokens survive a process restart and can be shared across instances
+// pointed at the same database. It expects a table of the shape:
+//
+//	CREATE TABLE tokens (
+//		token     VARCHAR(255) PRIMARY KEY,
+//		value     TEXT,
+//		expire_at TIMESTAMP NOT NULL
+//	);
+//
+// table defaults to "tokens" when created via NewSQLBackend.
+type SQLBackend struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSQLBackend creates a SQLBackend that stores tokens in the given table
+// through db. The table must already exist; see SQLBackend for its schema.
+func NewSQLBackend(db *sql.DB, table string) *SQLBackend {
+	if table == "" {
+		table = "tokens"
+	}
+	return &SQLBackend{db: db, table: table}
+}
+
+// AddValue stores value under a newly created token and reports whether the
+// token already existed.
+func (b *SQLBackend) AddValue(token string, value interface{}) (bool, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	var exists bool
+	row := b.db.QueryRow("SELECT 1 FROM "+b.table+" WHERE token = ?", token)
+	if err := row.Scan(&exists); err != nil && err != sql.ErrNoRows {
+		return false, err
+	} else if err == nil {
+		return true, nil
+	}
+
+	_, err = b.db.Exec(
+		"INSERT INTO "+b.table+" (token, value, expire_at) VALUES (?, ?, ?)",
+		token, string(encoded), time.Time{})
+	return false, err
+}
+
+// GetValue gets the value stored by specified token.
+func (b *SQLBackend) GetValue(token string) (interface{}, error) {
+	var encoded string
+	var expireAt time.Time
+	row := b.db.QueryRow(
+		"SELECT value, expire_at FROM "+b.table+" WHERE token = ?", token)
+	if err := row.Scan(&encoded, &expireAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("the requested token does not exist")
+		}
+		return nil, err
+	}
+
+	if !expireAt.IsZero() && time.Now().After(expireAt) {
+		return nil, errors.New("the requested token does not exist")
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(encoded), &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// SetValue stores a value to specified token, keeping its current expiry.
+func (b *SQLBackend) SetValue(token string, value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	res, err := b.db.Exec(
+		"UPDATE "+b.table+" SET value = ? WHERE token = ?", string(encoded), token)
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(res)
+}
+
+// RemoveValue removes specified token from the backend.
+func (b *SQLBackend) RemoveValue(token string) error {
+	res, err := b.db.Exec("DELETE FROM "+b.table+" WHERE token = ?", token)
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(res)
+}
+
+// SetValueDuration updates the lifetime of specified token.
+func (b *SQLBackend) SetValueDuration(token string, duration time.Duration) error {
+	res, err := b.db.Exec(
+		"UPDATE "+b.table+" SET expire_at = ? WHERE token = ?",
+		time.Now().Add(duration), token)
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(res)
+}
+
+// Count gets the number of non-expired tokens currently stored in the table.
+func (b *SQLBackend) Count() int {
+	var count int
+	row := b.db.QueryRow(
+		"SELECT COUNT(*) FROM "+b.table+" WHERE expire_at = ? OR expire_at > ?",
+		time.Time{}, time.Now())
+	if err := row.Scan(&count); err != nil {
+		return 0
+	}
+	return count
+}
+
+// requireRowsAffected turns a zero-rows-affected sql.Result into an invalid
+// token error, since every write in SQLBackend targets a single token.
+func requireRowsAffected(res sql.Result) error {
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("the requested token does not exist")
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright (C) 2015 Fabrício Godoy <skarllot@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, write to the Free Software
+ * Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+ */
+
+package appcontext
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// statelessSeparator splits the token fields and also replaces any colon
+// found in userID/actionID so that the MAC input cannot be ambiguated.
+const statelessSeparator = ":"
+
+// NewStatelessToken creates a token bound to userID and actionID that can be
+// validated without server-side storage. The token embeds its issue time so
+// that ValidateStatelessToken can enforce authDuration without a TimedStore
+// lookup, which allows horizontally scaled deployments to validate tokens
+// without sharing state.
+func (s *TokenStore) NewStatelessToken(userID, actionID string) string {
+	issuedAt := time.Now().UnixMilli()
+	mac := s.statelessMAC(userID, actionID, issuedAt)
+	return base64.URLEncoding.EncodeToString(mac) + statelessSeparator +
+		strconv.FormatInt(issuedAt, 10)
+}
+
+// ValidateStatelessToken checks that token was issued by this TokenStore for
+// userID and actionID and that it has not expired. It recomputes the MAC
+// from the embedded issuedAt and compares it using a constant-time
+// comparison to avoid leaking timing information.
+func (s *TokenStore) ValidateStatelessToken(token, userID, actionID string) error {
+	parts := strings.SplitN(token, statelessSeparator, 2)
+	if len(parts) != 2 {
+		return s.getInvalidTokenError(token)
+	}
+
+	macSum, err := base64.URLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return s.getInvalidTokenError(token)
+	}
+
+	issuedAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return s.getInvalidTokenError(token)
+	}
+
+	issuedAtTime := time.UnixMilli(issuedAt)
+	now := time.Now()
+	if issuedAtTime.After(now) {
+		return errors.New("the requested token was issued in the future")
+	}
+	if now.Sub(issuedAtTime) > s.authDuration {
+		return s.getInvalidTokenError(token)
+	}
+
+	expectedMAC := s.statelessMAC(userID, actionID, issuedAt)
+	if subtle.ConstantTimeCompare(macSum, expectedMAC) != 1 {
+		return s.getInvalidTokenError(token)
+	}
+
+	return nil
+}
+
+// statelessMAC computes the HMAC-SHA256 of userID, actionID and issuedAt,
+// replacing any statelessSeparator occurring inside userID/actionID so the
+// three fields cannot be confused with one another.
+func (s *TokenStore) statelessMAC(userID, actionID string, issuedAt int64) []byte {
+	safeUserID := strings.Replace(userID, statelessSeparator, "_", -1)
+	safeActionID := strings.Replace(actionID, statelessSeparator, "_", -1)
+
+	mac := hmac.New(sha256.New, s.salt)
+	mac.Write([]byte(safeUserID))
+	mac.Write([]byte(statelessSeparator))
+	mac.Write([]byte(safeActionID))
+	mac.Write([]byte(statelessSeparator))
+	mac.Write([]byte(strconv.FormatInt(issuedAt, 10)))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright (C) 2015 Fabrício Godoy <skarllot@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, write to the Free Software
+ * Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+ */
+
+package appcontext
+
+import (
+	"encoding/base64"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTokenStore_StatelessTokenRoundTrips(t *testing.T) {
+	store := newTestTokenStore()
+
+	token := store.NewStatelessToken("user-1", "reset-password")
+
+	if err := store.ValidateStatelessToken(token, "user-1", "reset-password"); err != nil {
+		t.Fatalf("ValidateStatelessToken: %v", err)
+	}
+}
+
+func TestTokenStore_StatelessTokenRejectsWrongIdentity(t *testing.T) {
+	store := newTestTokenStore()
+
+	token := store.NewStatelessToken("user-1", "reset-password")
+
+	if err := store.ValidateStatelessToken(token, "user-2", "reset-password"); err == nil {
+		t.Fatal("expected a token bound to a different userID to be rejected")
+	}
+	if err := store.ValidateStatelessToken(token, "user-1", "delete-account"); err == nil {
+		t.Fatal("expected a token bound to a different actionID to be rejected")
+	}
+}
+
+func TestTokenStore_StatelessTokenRejectsFutureIssuedAt(t *testing.T) {
+	store := newTestTokenStore()
+
+	future := time.Now().Add(time.Hour).UnixMilli()
+	mac := store.statelessMAC("user-1", "reset-password", future)
+	token := encodeStatelessToken(mac, future)
+
+	if err := store.ValidateStatelessToken(token, "user-1", "reset-password"); err == nil {
+		t.Fatal("expected a token issued in the future to be rejected")
+	}
+}
+
+func TestTokenStore_StatelessTokenRejectsExpired(t *testing.T) {
+	store := NewTokenStoreWithBackend(newMemoryBackend(), time.Hour, time.Millisecond, "salt")
+
+	past := time.Now().Add(-time.Hour).UnixMilli()
+	mac := store.statelessMAC("user-1", "reset-password", past)
+	token := encodeStatelessToken(mac, past)
+
+	if err := store.ValidateStatelessToken(token, "user-1", "reset-password"); err == nil {
+		t.Fatal("expected a token older than authDuration to be rejected")
+	}
+}
+
+func TestTokenStore_StatelessTokenRejectsTamperedMAC(t *testing.T) {
+	store := newTestTokenStore()
+
+	token := store.NewStatelessToken("user-1", "reset-password")
+	replacement := "x"
+	if token[0] == 'x' {
+		replacement = "y"
+	}
+	tampered := replacement + token[1:]
+
+	if err := store.ValidateStatelessToken(tampered, "user-1", "reset-password"); err == nil {
+		t.Fatal("expected a tampered MAC to be rejected")
+	}
+}
+
+func TestTokenStore_StatelessTokenRejectsColonCollisionAcrossFields(t *testing.T) {
+	store := newTestTokenStore()
+
+	token := store.NewStatelessToken("a:b", "c")
+
+	if err := store.ValidateStatelessToken(token, "a", "b:c"); err == nil {
+		t.Fatal("a userID/actionID split across the colon separator must not validate against the original fields")
+	}
+}
+
+func TestTokenStore_StatelessTokenRejectsMalformedInput(t *testing.T) {
+	store := newTestTokenStore()
+
+	cases := map[string]string{
+		"missing separator":   "nodotshere",
+		"malformed base64":    "not-base64!@#:1234567890",
+		"malformed issued at": "AAAA:not-a-number",
+	}
+
+	for name, token := range cases {
+		t.Run(name, func(t *testing.T) {
+			if err := store.ValidateStatelessToken(token, "user-1", "reset-password"); err == nil {
+				t.Fatalf("expected malformed token (%s) to be rejected", name)
+			}
+		})
+	}
+}
+
+// encodeStatelessToken builds a stateless token string from a precomputed
+// MAC and issuedAt, mirroring NewStatelessToken's own encoding so tests can
+// exercise issuedAt values NewStatelessToken itself would never produce
+// (e.g. in the future, or far in the past).
+func encodeStatelessToken(mac []byte, issuedAt int64) string {
+	return base64.URLEncoding.EncodeToString(mac) + statelessSeparator + strconv.FormatInt(issuedAt, 10)
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright (C) 2015 Fabrício Godoy <skarllot@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, write to the Free Software
+ * Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+ */
+
+package appcontext
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// timedStoreEntry is a single value tracked by TimedStore, along with the
+// time at which it stops being valid.
+type timedStoreEntry struct {
+	value    interface{}
+	expireAt time.Time
+}
+
+// A TimedStore is an in-memory Backend that expires entries after a
+// configurable duration. It is the default Backend used by NewTokenStore.
+type TimedStore struct {
+	mu            sync.Mutex
+	entries       map[string]timedStoreEntry
+	defaultExpiry time.Duration
+}
+
+// NewTimedStore creates a TimedStore whose entries expire after
+// defaultExpiry unless their duration is changed with SetValueDuration.
+func NewTimedStore(defaultExpiry time.Duration) *TimedStore {
+	return &TimedStore{
+		entries:       make(map[string]timedStoreEntry),
+		defaultExpiry: defaultExpiry,
+	}
+}
+
+// AddValue stores value under a newly created token and reports whether the
+// token already existed.
+func (s *TimedStore) AddValue(token string, value interface{}) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[token]; ok && !s.expired(e) {
+		return true, nil
+	}
+
+	entry := timedStoreEntry{value: value}
+	if s.defaultExpiry > 0 {
+		entry.expireAt = time.Now().Add(s.defaultExpiry)
+	}
+	s.entries[token] = entry
+	return false, nil
+}
+
+// GetValue gets the value stored by specified token.
+func (s *TimedStore) GetValue(token string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[token]
+	if !ok || s.expired(e) {
+		return nil, errors.New("the requested token does not exist")
+	}
+	return e.value, nil
+}
+
+// SetValue stores a value to specified token, keeping its current expiry.
+func (s *TimedStore) SetValue(token string, value interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[token]
+	if !ok || s.expired(e) {
+		return errors.New("the requested token does not exist")
+	}
+	e.value = value
+	s.entries[token] = e
+	return nil
+}
+
+// RemoveValue removes specified token from the store.
+func (s *TimedStore) RemoveValue(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[token]; !ok {
+		return errors.New("the requested token does not exist")
+	}
+	delete(s.entries, token)
+	return nil
+}
+
+// SetValueDuration updates the lifetime of specified token.
+func (s *TimedStore) SetValueDuration(token string, duration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[token]
+	if !ok || s.expired(e) {
+		return errors.New("the requested token does not exist")
+	}
+	e.expireAt = time.Now().Add(duration)
+	s.entries[token] = e
+	return nil
+}
+
+// Count gets the number of non-expired tokens currently stored.
+func (s *TimedStore) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, e := range s.entries {
+		if !s.expired(e) {
+			count++
+		}
+	}
+	return count
+}
+
+// expired reports whether entry has passed its expiry. A zero expireAt
+// means the entry never expires.
+func (s *TimedStore) expired(e timedStoreEntry) bool {
+	return !e.expireAt.IsZero() && time.Now().After(e.expireAt)
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright (C) 2015 Fabrício Godoy <skarllot@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, write to the Free Software
+ * Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+ */
+
+package appcontext
+
+import "time"
+
+// tokenMeta tracks the auditing information NewToken records for a token,
+// separately from the arbitrary application value callers attach via
+// SetValue.
+type tokenMeta struct {
+	issuedAt        time.Time
+	authenticated   bool
+	authenticatedAt time.Time
+}
+
+// TokenInfo reports when token was issued, when it is due to expire and
+// whether it has been marked authenticated, so that callers can build
+// admin dashboards or enforce their own XSRF-style timeout on top of
+// TokenStore. It only covers tokens created through NewToken or
+// NewTokenPair; stateless and JWT tokens carry their own expiry and do not
+// need this lookup.
+//
+// expiresAt for an unauthenticated token reflects the noAuth duration
+// passed to NewTokenStore or NewTokenStoreWithBackend.
+func (s *TokenStore) TokenInfo(token string) (issuedAt, expiresAt time.Time, authenticated bool, err error) {
+	s.mu.Lock()
+	m, ok := s.meta[token]
+	s.mu.Unlock()
+	if !ok {
+		return time.Time{}, time.Time{}, false, s.getInvalidTokenError(token)
+	}
+
+	if _, err := s.tstore.GetValue(token); err != nil {
+		return time.Time{}, time.Time{}, false, s.getInvalidTokenError(token)
+	}
+
+	// The backend-enforced expiry set by SetTokenAsAuthenticated is
+	// authDuration from the moment authentication happened, not from
+	// issuance, so an authenticated token's expiresAt must be computed from
+	// authenticatedAt rather than issuedAt.
+	from := m.issuedAt
+	duration := s.noAuthDuration
+	if m.authenticated {
+		from = m.authenticatedAt
+		duration = s.authDuration
+	}
+
+	var expires time.Time
+	if duration > 0 {
+		expires = from.Add(duration)
+	}
+
+	return m.issuedAt, expires, m.authenticated, nil
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright (C) 2015 Fabrício Godoy <skarllot@gmail.com>
+ *
+ * This program is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License
+ * as published by the Free Software Foundation; either version 2
+ * of the License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program; if not, write to the Free Software
+ * Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+ */
+
+package appcontext
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTokenStore_NewTokenConcurrentIsSafeAndUnique(t *testing.T) {
+	store := newTestTokenStore()
+
+	const callers = 50
+	tokens := make(chan string, callers)
+	var wg sync.WaitGroup
+
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tokens <- store.NewToken()
+		}()
+	}
+	wg.Wait()
+	close(tokens)
+
+	seen := make(map[string]bool, callers)
+	for tok := range tokens {
+		if seen[tok] {
+			t.Fatalf("NewToken produced a duplicate token under concurrent use: %s", tok)
+		}
+		seen[tok] = true
+	}
+	if len(seen) != callers {
+		t.Fatalf("expected %d unique tokens, got %d", callers, len(seen))
+	}
+}
+
+func TestTokenStore_TokenInfoReflectsAuthentication(t *testing.T) {
+	store := NewTokenStoreWithBackend(newMemoryBackend(), time.Hour, 2*time.Hour, "salt")
+
+	token := store.NewToken()
+
+	issuedAt, expiresAt, authenticated, err := store.TokenInfo(token)
+	if err != nil {
+		t.Fatalf("TokenInfo: %v", err)
+	}
+	if authenticated {
+		t.Fatal("a freshly created token should not be reported as authenticated")
+	}
+	if !expiresAt.Equal(issuedAt.Add(time.Hour)) {
+		t.Fatalf("expected expiresAt to reflect the noAuth duration, got %v", expiresAt)
+	}
+
+	// Authenticate well after issuance, so that an expiresAt still anchored
+	// to issuedAt rather than the moment of authentication would be caught.
+	time.Sleep(20 * time.Millisecond)
+	beforeAuth := time.Now()
+	if err := store.SetTokenAsAuthenticated(token); err != nil {
+		t.Fatalf("SetTokenAsAuthenticated: %v", err)
+	}
+
+	issuedAt2, expiresAt2, authenticated2, err := store.TokenInfo(token)
+	if err != nil {
+		t.Fatalf("TokenInfo after auth: %v", err)
+	}
+	if !authenticated2 {
+		t.Fatal("token should be reported as authenticated after SetTokenAsAuthenticated")
+	}
+	if !issuedAt2.Equal(issuedAt) {
+		t.Fatal("issuedAt should not change when a token is authenticated")
+	}
+	if expiresAt2.Before(beforeAuth.Add(2*time.Hour)) || expiresAt2.Equal(issuedAt2.Add(2*time.Hour)) {
+		t.Fatalf("expected expiresAt to reflect the auth duration from the time of authentication, not issuance, got %v", expiresAt2)
+	}
+}
+
+func TestTokenStore_TokenInfoUnknownToken(t *testing.T) {
+	store := newTestTokenStore()
+
+	if _, _, _, err := store.TokenInfo("does-not-exist"); err == nil {
+		t.Fatal("expected an error for an unknown token")
+	}
+}
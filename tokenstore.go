@@ -25,27 +25,51 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 )
 
 // A TokenStore provides a temporary token to uniquely identify an user session.
 type TokenStore struct {
-	tstore       *TimedStore
-	salt         []byte
-	authDuration time.Duration
+	mu sync.Mutex
+
+	// refreshMu serializes Refresh/RemoveToken/SetTokenAsAuthenticated's access to the
+	// refresh-token bookkeeping so that a refresh token cannot be redeemed
+	// by two concurrent callers (see refresh_token.go).
+	refreshMu sync.Mutex
+
+	tstore          Backend
+	salt            []byte
+	authDuration    time.Duration
+	refreshDuration time.Duration
+	noAuthDuration  time.Duration
+	meta            map[string]tokenMeta
 }
 
 // NewTokenStore creates a new instance of TokenStore and defines a lifetime for
 // unauthenticated and authenticated sessions and a salt for random input.
+// Tokens are kept in an in-memory TimedStore; use NewTokenStoreWithBackend to
+// persist them to a durable or shared Backend instead.
 func NewTokenStore(noAuth, auth time.Duration, salt string) *TokenStore {
+	return NewTokenStoreWithBackend(NewTimedStore(noAuth), noAuth, auth, salt)
+}
+
+// NewTokenStoreWithBackend creates a new instance of TokenStore backed by the
+// given Backend, so that callers can plug in a durable or shared store (e.g.
+// FileBackend or SQLBackend) in place of the default in-memory TimedStore.
+// noAuth and auth are applied by TokenStore itself via SetValueDuration, so
+// the lifetime of unauthenticated and authenticated tokens is enforced the
+// same way regardless of which Backend is in use.
+func NewTokenStoreWithBackend(backend Backend, noAuth, auth time.Duration, salt string) *TokenStore {
 	hash := sha256.New()
 	hash.Write([]byte(salt))
 
-	ts := NewTimedStore(noAuth)
 	return &TokenStore{
-		tstore:       ts,
-		salt:         hash.Sum(nil),
-		authDuration: auth,
+		tstore:         backend,
+		salt:           hash.Sum(nil),
+		authDuration:   auth,
+		noAuthDuration: noAuth,
+		meta:           make(map[string]tokenMeta),
 	}
 }
 
@@ -70,47 +94,85 @@ func (s *TokenStore) GetValue(token string) (interface{}, error) {
 	return v, err
 }
 
+// tokenInfo is the key derivation context passed as HKDF "info" when
+// deriving per-token keys, binding them to their purpose.
+const tokenInfo = "contextstore.NewToken"
+
 // NewToken creates a new unique token and stores it into current TokenStore
-// instance.
+// instance. It is safe for concurrent use by multiple goroutines: each call
+// derives its own per-token key via HKDF-SHA256 from the store's root salt
+// and a fresh random nonce, so tokens no longer depend on the entropy
+// carried over from previous calls.
 func (s *TokenStore) NewToken() string {
-	mac := hmac.New(sha256.New, s.salt)
-	now := time.Now().Format(time.RFC3339Nano)
-
-	// Tries to create unpredictable token
-	// Most strength comes from 'rand.Read'
-	// Another bits are used to avoid the chance of system random genarator
-	//   is compromissed by internal issue
-	mac.Write(getRandomBytes(128))
-	mac.Write(getRandomBytes(time.Now().Second() / 2))
-	mac.Write([]byte(now))
+	nonce := getRandomBytes(32)
+	key := hkdfSHA256(s.salt, nonce, []byte(tokenInfo), sha256.Size)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(nonce)
+	mac.Write([]byte(time.Now().Format(time.RFC3339Nano)))
 	macSum := mac.Sum(nil)
-	s.salt = macSum
 	strSum := base64.URLEncoding.EncodeToString(macSum)
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	_, err := s.tstore.AddValue(strSum, nil)
 	if err != nil {
 		panic("Something is seriously wrong, a duplicated token was generated")
 	}
 
+	// AddValue alone does not expire the token on every Backend (e.g.
+	// FileBackend, SQLBackend, KVBackend store it with no expiry), so the
+	// noAuth lifetime must be applied explicitly here rather than assumed.
+	if err := s.tstore.SetValueDuration(strSum, s.noAuthDuration); err != nil {
+		panic("Something is seriously wrong, a just-created token vanished")
+	}
+	s.meta[strSum] = tokenMeta{issuedAt: time.Now()}
+
 	return strSum
 }
 
-// RemoveToken removes specified token from current TokenStore instance.
+// RemoveToken removes specified token from current TokenStore instance. If
+// token is the access or refresh half of a pair created by NewTokenPair, its
+// counterpart is revoked as well. token may be either the access token or
+// the refresh token of a pair; refresh tokens are never stored under their
+// own bare key, so they are looked up and revoked separately.
 func (s *TokenStore) RemoveToken(token string) error {
+	if s.removeByRefreshToken(token) == nil {
+		return nil
+	}
+
 	err := s.tstore.RemoveValue(token)
 	if err != nil {
 		return s.getInvalidTokenError(token)
 	}
+
+	s.mu.Lock()
+	delete(s.meta, token)
+	s.mu.Unlock()
+
+	s.revokePairedToken(token)
 	return nil
 }
 
 // SetTokenAsAuthenticated updates the lifetime of specified token to specified
-// lifetime for authenticated sessions.
+// lifetime for authenticated sessions. If token is the access half of a pair
+// created by NewTokenPair, its refresh token's lifetime is renewed as well.
 func (s *TokenStore) SetTokenAsAuthenticated(token string) error {
 	err := s.tstore.SetValueDuration(token, s.authDuration)
 	if err != nil {
 		return s.getInvalidTokenError(token)
 	}
+
+	s.mu.Lock()
+	if m, ok := s.meta[token]; ok {
+		m.authenticated = true
+		m.authenticatedAt = time.Now()
+		s.meta[token] = m
+	}
+	s.mu.Unlock()
+
+	s.renewPairedRefreshToken(token)
 	return nil
 }
 